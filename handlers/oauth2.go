@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/influxdata/chronograf"
+	"github.com/influxdata/chronograf/handlers/providers"
+	"golang.org/x/oauth2"
+)
+
+// Provider abstracts a single OAuth2 identity provider (Github, Google, a
+// generic OIDC issuer, ...) so that OAuth2 can drive Login/Callback/Logout
+// without knowing which provider it is talking to.
+type Provider interface {
+	// Name uniquely identifies the provider and is used to mount its routes
+	// under /oauth/{name}/login and /oauth/{name}/callback.
+	Name() string
+	// Config returns the oauth2.Config used to drive the authorization code
+	// flow for this provider.
+	Config() *oauth2.Config
+	// PrincipalID exchanges an already-obtained token for the stable
+	// identifier (typically an email address) Chronograf should use as the
+	// user's principal. It is also where provider-specific authorization
+	// checks (org/team/domain restrictions) belong.
+	PrincipalID(ctx context.Context, token *oauth2.Token) (string, error)
+}
+
+// OAuth2 provides provider-agnostic Login and Callback handlers. Callback
+// will set an authentication cookie. This cookie's value is a JWT containing
+// the principal returned by the configured Provider, unless Store is set,
+// in which case Store encrypts the principal (and upstream token) across
+// one or more cookies instead.
+type OAuth2 struct {
+	Provider      Provider
+	Cookie        Cookie
+	Authenticator chronograf.Authenticator
+	Store         *SessionStore // Store, if set, encrypts sessions instead of relying on Authenticator's bare JWT cookie
+	SuccessURL    string        // SuccessURL is redirect location after successful authorization
+	FailureURL    string        // FailureURL is redirect location after authorization failure
+	Now           func() time.Time
+	Logger        chronograf.Logger
+
+	refreshes refreshGroup // de-duplicates concurrent upstream token refreshes; see RefreshToken
+}
+
+// NewOAuth2 constructs an OAuth2 with default cookie behavior for provider.
+func NewOAuth2(provider Provider, successURL, failureURL string, auth chronograf.Authenticator, log chronograf.Logger) OAuth2 {
+	return OAuth2{
+		Provider:      provider,
+		Cookie:        NewCookie(),
+		SuccessURL:    successURL,
+		FailureURL:    failureURL,
+		Authenticator: auth,
+		Now:           time.Now,
+		Logger:        log,
+	}
+}
+
+// loginAuthCodeOptions requests offline access and forces the consent
+// screen on every login. Without AccessTypeOffline, Google (and most
+// strict OIDC providers) never issues a refresh token at all, which would
+// leave the background refresher from RefreshToken permanently inert;
+// forcing the consent prompt ensures a refresh token comes back on every
+// login rather than only the user's first-ever consent.
+var loginAuthCodeOptions = []oauth2.AuthCodeOption{
+	oauth2.AccessTypeOffline,
+	oauth2.SetAuthURLParam("prompt", "consent"),
+}
+
+// Login returns a handler that redirects to the provider's OAuth login.
+// Uses JWT with a random string as the state validation method. JWTs are
+// used because they can be validated without storing state.
+func (o *OAuth2) Login() http.Handler {
+	conf := o.Provider.Config()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// We are creating a token with an encoded random string to prevent CSRF attacks
+		// This token will be validated during the OAuth callback.
+		// We'll give our users 10 minutes from this point to complete authorization.
+		// If the callback is not received within 10 minutes, then authorization will fail.
+		csrf := randomString(32) // 32 is not important... just long
+		state, err := o.Authenticator.Token(r.Context(), chronograf.Principal(csrf), 10*time.Minute)
+		// This is likely an internal server error
+		if err != nil {
+			o.log(r).Error("Internal authentication error: ", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		url := conf.AuthCodeURL(state, loginAuthCodeOptions...)
+		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	})
+}
+
+// Logout will expire our authentication cookie(s) and redirect to the
+// SuccessURL
+func (o *OAuth2) Logout() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.Store != nil {
+			o.Store.Clear(w, o.Cookie)
+		} else {
+			http.SetCookie(w, &http.Cookie{
+				Name:     o.Cookie.Name,
+				Value:    "none",
+				Expires:  o.Now().Add(-1 * time.Hour),
+				HttpOnly: true,
+				Path:     "/",
+			})
+		}
+		http.Redirect(w, r, o.SuccessURL, http.StatusTemporaryRedirect)
+	})
+}
+
+// Callback used by the provider's callback endpoint after authorization is
+// granted. If granted, Callback will set a cookie with a month-long
+// expiration. The value of the cookie is a JWT because the JWT can be
+// validated without the need for saving state. The JWT contains the
+// principal returned by Provider.PrincipalID. If Store is set, Store.Save
+// writes the encrypted principal and upstream token instead of the JWT.
+func (o *OAuth2) Callback() http.Handler {
+	conf := o.Provider.Config()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := o.log(r)
+
+		state := r.FormValue("state")
+		// Check if the OAuth state token is valid to prevent CSRF
+		_, err := o.Authenticator.Authenticate(r.Context(), state)
+		if err != nil {
+			log.Error("Invalid OAuth state received: ", err.Error())
+			http.Redirect(w, r, o.FailureURL, http.StatusTemporaryRedirect)
+			return
+		}
+
+		code := r.FormValue("code")
+		token, err := conf.Exchange(r.Context(), code)
+		if err != nil {
+			log.Error("Unable to exchange code for token ", err.Error())
+			http.Redirect(w, r, o.FailureURL, http.StatusTemporaryRedirect)
+			return
+		}
+
+		principal, err := o.Provider.PrincipalID(r.Context(), token)
+		if err != nil {
+			if denied, ok := err.(*providers.DeniedError); ok {
+				log.Info("Denied authentication for ", denied.Email, ": ", denied.Reason)
+			} else {
+				log.Error("Unable to authenticate with ", o.Provider.Name(), ": ", err.Error())
+			}
+			http.Redirect(w, r, o.FailureURL, http.StatusTemporaryRedirect)
+			return
+		}
+
+		if o.Store != nil {
+			if err := o.Store.Save(w, o.Cookie, chronograf.Principal(principal), o.Provider.Name(), token); err != nil {
+				log.Error("Unable to save session ", err.Error())
+				http.Redirect(w, r, o.FailureURL, http.StatusTemporaryRedirect)
+				return
+			}
+		} else {
+			// We create an auth token that will be used by all other endpoints to validate the principal has a claim
+			authToken, err := o.Authenticator.Token(r.Context(), chronograf.Principal(principal), o.Cookie.Duration)
+			if err != nil {
+				log.Error("Unable to create cookie auth token ", err.Error())
+				http.Redirect(w, r, o.FailureURL, http.StatusTemporaryRedirect)
+				return
+			}
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     o.Cookie.Name,
+				Value:    authToken,
+				Expires:  o.Now().Add(o.Cookie.Duration),
+				HttpOnly: true,
+				Path:     "/",
+			})
+		}
+
+		log.Info("User ", principal, " is authenticated with ", o.Provider.Name())
+		http.Redirect(w, r, o.SuccessURL, http.StatusTemporaryRedirect)
+	})
+}
+
+func (o *OAuth2) log(r *http.Request) chronograf.Logger {
+	return o.Logger.
+		WithField("component", "auth").
+		WithField("remote_addr", r.RemoteAddr).
+		WithField("method", r.Method).
+		WithField("url", r.URL)
+}
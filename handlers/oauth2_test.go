@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestLoginAuthCodeOptionsRequestOfflineAccess(t *testing.T) {
+	conf := &oauth2.Config{
+		ClientID: "client-id",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/authorize"},
+	}
+
+	url := conf.AuthCodeURL("state", loginAuthCodeOptions...)
+
+	if !strings.Contains(url, "access_type=offline") {
+		t.Errorf("auth URL %q does not request offline access", url)
+	}
+	if !strings.Contains(url, "prompt=consent") {
+		t.Errorf("auth URL %q does not force the consent prompt", url)
+	}
+}
@@ -0,0 +1,54 @@
+package providers
+
+import (
+	"strings"
+)
+
+// DeniedError indicates that an otherwise successfully authenticated user
+// was rejected by the domain or email allow-list rather than by an
+// upstream API failure. Callers can use this to log denials at a lower
+// severity than unexpected errors. Github organization and team membership
+// rejections are returned as plain errors, not as DeniedError.
+type DeniedError struct {
+	Email  string
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return e.Reason
+}
+
+// emailAllowed reports whether email is permitted by domains and emails.
+// An empty domains and emails means allow-all. emails is matched
+// case-insensitively and in full; domains is matched against the host
+// portion of email, with entries of the form "*.example.com" additionally
+// matching any subdomain of example.com.
+func emailAllowed(email string, domains, emails []string) bool {
+	if len(domains) == 0 && len(emails) == 0 {
+		return true
+	}
+
+	for _, allowed := range emails {
+		if strings.EqualFold(email, allowed) {
+			return true
+		}
+	}
+
+	host := email
+	if i := strings.LastIndex(email, "@"); i != -1 {
+		host = email[i+1:]
+	}
+
+	for _, domain := range domains {
+		wildcard := strings.HasPrefix(domain, "*.")
+		domain = strings.TrimPrefix(domain, "*.")
+		if strings.EqualFold(host, domain) {
+			return true
+		}
+		if wildcard && strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(domain)) {
+			return true
+		}
+	}
+
+	return false
+}
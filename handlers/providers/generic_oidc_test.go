@@ -0,0 +1,81 @@
+package providers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func idToken(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	body, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshaling claims: %v", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	return strings.Join([]string{header, payload, ""}, ".")
+}
+
+func TestGenericPrincipalID(t *testing.T) {
+	issuer := "https://idp.example.com"
+	clientID := "chronograf"
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "valid token with a single string audience is accepted",
+			claims: map[string]interface{}{"email": "user@example.com", "iss": issuer, "aud": clientID, "exp": future},
+		},
+		{
+			name:   "valid token with an array audience is accepted",
+			claims: map[string]interface{}{"email": "user@example.com", "iss": issuer, "aud": []string{"other-client", clientID}, "exp": future},
+		},
+		{
+			name:    "mismatched audience is rejected",
+			claims:  map[string]interface{}{"email": "user@example.com", "iss": issuer, "aud": "some-other-client", "exp": future},
+			wantErr: true,
+		},
+		{
+			name:    "mismatched issuer is rejected",
+			claims:  map[string]interface{}{"email": "user@example.com", "iss": "https://attacker.example.com", "aud": clientID, "exp": future},
+			wantErr: true,
+		},
+		{
+			name:    "expired token is rejected",
+			claims:  map[string]interface{}{"email": "user@example.com", "iss": issuer, "aud": clientID, "exp": past},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Generic{Issuer: issuer, ClientID: clientID}
+			tok := (&oauth2.Token{}).WithExtra(map[string]interface{}{"id_token": idToken(t, tt.claims)})
+
+			email, err := g.PrincipalID(nil, tok)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got email %q", email)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PrincipalID returned error: %v", err)
+			}
+			if email != "user@example.com" {
+				t.Errorf("got email %q, want %q", email, "user@example.com")
+			}
+		})
+	}
+}
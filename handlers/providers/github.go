@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
+	ogh "golang.org/x/oauth2/github"
+)
+
+// Github is an OAuth2 Provider for Github. If Organizations and/or Teams
+// are set, PrincipalID additionally requires the authenticated user to
+// belong to one of them before returning a principal.
+type Github struct {
+	ClientID       string
+	ClientSecret   string
+	Organizations  []string // Organizations, if set, restricts authentication to members of at least one of these Github organizations
+	Teams          []string // Teams, if set, restricts authentication to members of at least one of these Github teams (specified as "org/team")
+	AllowedDomains []string // AllowedDomains, if set, restricts authentication to emails whose domain matches one of these entries; "*.example.com" also matches subdomains
+	AllowedEmails  []string // AllowedEmails, if set, restricts authentication to these exact email addresses
+}
+
+// Name returns the name of this provider ("github").
+func (g *Github) Name() string {
+	return "github"
+}
+
+// Config returns the OAuth2 configuration for Github, adding the read:org
+// scope automatically when organization or team membership is restricted.
+func (g *Github) Config() *oauth2.Config {
+	scopes := []string{"user:email"}
+	if len(g.Organizations) > 0 || len(g.Teams) > 0 {
+		scopes = append(scopes, "read:org")
+	}
+	return &oauth2.Config{
+		ClientID:     g.ClientID,
+		ClientSecret: g.ClientSecret,
+		Scopes:       scopes,
+		Endpoint:     ogh.Endpoint,
+	}
+}
+
+// PrincipalID verifies org/team membership, if configured, and returns the
+// authenticated user's primary, verified Github email address.
+func (g *Github) PrincipalID(ctx context.Context, token *oauth2.Token) (string, error) {
+	client := github.NewClient(g.Config().Client(ctx, token))
+
+	if ok, err := g.isMember(client); !ok {
+		return "", err
+	}
+
+	emails, _, err := client.Users.ListEmails(nil)
+	if err != nil {
+		return "", err
+	}
+
+	email, err := primaryEmail(emails)
+	if err != nil {
+		return "", err
+	}
+
+	if !emailAllowed(email, g.AllowedDomains, g.AllowedEmails) {
+		return "", &DeniedError{Email: email, Reason: "email " + email + " is not in an allowed domain or email list"}
+	}
+
+	return email, nil
+}
+
+func (g *Github) isMember(client *github.Client) (bool, error) {
+	if len(g.Organizations) == 0 && len(g.Teams) == 0 {
+		return true, nil
+	}
+
+	if len(g.Organizations) > 0 {
+		ok, err := inOrganizations(client, g.Organizations)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, errors.New("user is not a member of an allowed Github organization")
+		}
+	}
+
+	if len(g.Teams) > 0 {
+		ok, err := inTeams(client, g.Teams)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, errors.New("user is not a member of an allowed Github team")
+		}
+	}
+
+	return true, nil
+}
+
+func inOrganizations(client *github.Client, orgs []string) (bool, error) {
+	memberships, _, err := client.Organizations.ListOrgMemberships(nil)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range memberships {
+		if m == nil || m.State == nil || *m.State != "active" {
+			// Skip pending (unaccepted invitation) memberships; only an
+			// active membership means the user has actually joined.
+			continue
+		}
+		if m.Organization == nil || m.Organization.Login == nil {
+			continue
+		}
+		for _, org := range orgs {
+			if *m.Organization.Login == org {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func inTeams(client *github.Client, teams []string) (bool, error) {
+	userTeams, _, err := client.Teams.ListUserTeams(nil)
+	if err != nil {
+		return false, err
+	}
+	for _, t := range userTeams {
+		if t == nil || t.Slug == nil || t.Organization == nil || t.Organization.Login == nil {
+			continue
+		}
+		// Teams is configured as "org/team" using the team's slug (its
+		// URL-safe identifier), not its free-form display Name.
+		full := *t.Organization.Login + "/" + *t.Slug
+		for _, allowed := range teams {
+			if full == allowed {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func primaryEmail(emails []*github.UserEmail) (string, error) {
+	for _, m := range emails {
+		if m != nil && m.Primary != nil && m.Verified != nil && m.Email != nil && *m.Primary && *m.Verified {
+			return *m.Email, nil
+		}
+	}
+	return "", errors.New("no primary Github email address")
+}
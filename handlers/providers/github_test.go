@@ -0,0 +1,257 @@
+package providers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// newTestClient returns a go-github client whose API calls are served by
+// mux, along with a func to tear the server down.
+func newTestClient(t *testing.T) (*github.Client, *http.ServeMux, func()) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	client := github.NewClient(nil)
+	base, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("parsing test server URL: %v", err)
+	}
+	client.BaseURL = base
+
+	return client, mux, server.Close
+}
+
+func writeJSON(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, body)
+}
+
+func TestInOrganizations(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		orgs []string
+		want bool
+	}{
+		{
+			name: "active membership in an allowed org matches",
+			body: `[{"state":"active","organization":{"login":"influxdata"}}]`,
+			orgs: []string{"influxdata"},
+			want: true,
+		},
+		{
+			name: "pending membership does not match",
+			body: `[{"state":"pending","organization":{"login":"influxdata"}}]`,
+			orgs: []string{"influxdata"},
+			want: false,
+		},
+		{
+			name: "active membership in an org not on the list does not match",
+			body: `[{"state":"active","organization":{"login":"other-org"}}]`,
+			orgs: []string{"influxdata"},
+			want: false,
+		},
+		{
+			name: "no memberships does not match",
+			body: `[]`,
+			orgs: []string{"influxdata"},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mux, teardown := newTestClient(t)
+			defer teardown()
+
+			mux.HandleFunc("/user/memberships/orgs", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.body)
+			})
+
+			got, err := inOrganizations(client, tt.orgs)
+			if err != nil {
+				t.Fatalf("inOrganizations returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("inOrganizations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInTeams(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		teams []string
+		want  bool
+	}{
+		{
+			name:  "slug matches the configured org/team",
+			body:  `[{"slug":"platform-team","name":"Platform Team","organization":{"login":"influxdata"}}]`,
+			teams: []string{"influxdata/platform-team"},
+			want:  true,
+		},
+		{
+			name:  "display name alone does not match",
+			body:  `[{"slug":"platform-team","name":"Platform Team","organization":{"login":"influxdata"}}]`,
+			teams: []string{"influxdata/Platform Team"},
+			want:  false,
+		},
+		{
+			name:  "team in a different org does not match",
+			body:  `[{"slug":"platform-team","name":"Platform Team","organization":{"login":"other-org"}}]`,
+			teams: []string{"influxdata/platform-team"},
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mux, teardown := newTestClient(t)
+			defer teardown()
+
+			mux.HandleFunc("/user/teams", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.body)
+			})
+
+			got, err := inTeams(client, tt.teams)
+			if err != nil {
+				t.Fatalf("inTeams returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("inTeams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGithubIsMember(t *testing.T) {
+	tests := []struct {
+		name        string
+		memberships string
+		teams       string
+		provider    Github
+		want        bool
+		wantErr     bool
+	}{
+		{
+			name:     "no restriction configured always passes",
+			provider: Github{},
+			want:     true,
+		},
+		{
+			name:        "org restriction satisfied by an active membership",
+			memberships: `[{"state":"active","organization":{"login":"influxdata"}}]`,
+			provider:    Github{Organizations: []string{"influxdata"}},
+			want:        true,
+		},
+		{
+			name:        "org restriction rejects a merely pending invitation",
+			memberships: `[{"state":"pending","organization":{"login":"influxdata"}}]`,
+			provider:    Github{Organizations: []string{"influxdata"}},
+			wantErr:     true,
+		},
+		{
+			name:        "team restriction satisfied by slug",
+			memberships: `[]`,
+			teams:       `[{"slug":"platform-team","name":"Platform Team","organization":{"login":"influxdata"}}]`,
+			provider:    Github{Teams: []string{"influxdata/platform-team"}},
+			want:        true,
+		},
+		{
+			name:        "org satisfied but required team missing",
+			memberships: `[{"state":"active","organization":{"login":"influxdata"}}]`,
+			teams:       `[]`,
+			provider:    Github{Organizations: []string{"influxdata"}, Teams: []string{"influxdata/platform-team"}},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mux, teardown := newTestClient(t)
+			defer teardown()
+
+			mux.HandleFunc("/user/memberships/orgs", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.memberships)
+			})
+			mux.HandleFunc("/user/teams", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.teams)
+			})
+
+			got, err := tt.provider.isMember(client)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("isMember returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isMember() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrimaryEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		emails  []*github.UserEmail
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "returns the primary, verified email",
+			emails: []*github.UserEmail{
+				strPtrEmail("secondary@example.com", false, true),
+				strPtrEmail("primary@example.com", true, true),
+			},
+			want: "primary@example.com",
+		},
+		{
+			name: "an unverified primary email is not returned",
+			emails: []*github.UserEmail{
+				strPtrEmail("primary@example.com", true, false),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no emails is an error",
+			emails:  nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := primaryEmail(tt.emails)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("primaryEmail returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("primaryEmail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func strPtrEmail(email string, primary, verified bool) *github.UserEmail {
+	return &github.UserEmail{Email: &email, Primary: &primary, Verified: &verified}
+}
@@ -0,0 +1,80 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	ggl "golang.org/x/oauth2/google"
+)
+
+const googleUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
+
+// Google is an OAuth2 Provider backed by Google's identity platform. If
+// Domain is set, PrincipalID additionally requires the authenticated
+// account's email to belong to that hosted domain.
+type Google struct {
+	ClientID       string
+	ClientSecret   string
+	Domain         string   // Domain, if set, restricts authentication to this Google Apps hosted domain
+	AllowedDomains []string // AllowedDomains, if set, restricts authentication to emails whose domain matches one of these entries; "*.example.com" also matches subdomains
+	AllowedEmails  []string // AllowedEmails, if set, restricts authentication to these exact email addresses
+}
+
+// Name returns the name of this provider ("google").
+func (g *Google) Name() string {
+	return "google"
+}
+
+// Config returns the OAuth2 configuration for Google.
+func (g *Google) Config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     g.ClientID,
+		ClientSecret: g.ClientSecret,
+		Scopes:       []string{"email"},
+		Endpoint:     ggl.Endpoint,
+	}
+}
+
+// googleUserInfo mirrors the fields we use from Google's userinfo endpoint.
+type googleUserInfo struct {
+	Email         string `json:"email"`
+	VerifiedEmail bool   `json:"verified_email"`
+	Hd            string `json:"hd"`
+}
+
+// PrincipalID retrieves the authenticated account's verified email address
+// from Google's userinfo endpoint, enforcing Domain if configured.
+func (g *Google) PrincipalID(ctx context.Context, token *oauth2.Token) (string, error) {
+	client := g.Config().Client(ctx, token)
+	resp, err := client.Get(googleUserInfoURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("unable to retrieve Google userinfo: " + resp.Status)
+	}
+
+	var info googleUserInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", err
+	}
+
+	if !info.VerifiedEmail {
+		return "", errors.New("Google account email is not verified")
+	}
+
+	if g.Domain != "" && info.Hd != g.Domain {
+		return "", errors.New("Google account does not belong to domain " + g.Domain)
+	}
+
+	if !emailAllowed(info.Email, g.AllowedDomains, g.AllowedEmails) {
+		return "", &DeniedError{Email: info.Email, Reason: "email " + info.Email + " is not in an allowed domain or email list"}
+	}
+
+	return info.Email, nil
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultRefreshWindow is how far ahead of a token's expiry RefreshToken
+// will proactively refresh it.
+const DefaultRefreshWindow = time.Minute
+
+// RefreshingTokenSource refreshes an upstream OAuth token shortly before it
+// expires, so that a long-lived session cookie doesn't force re-login every
+// time the much shorter-lived upstream access token ages out.
+type RefreshingTokenSource struct {
+	conf *oauth2.Config
+	ctx  context.Context
+	now  func() time.Time
+}
+
+// NewRefreshingTokenSource returns a RefreshingTokenSource that refreshes
+// tokens using conf's token endpoint.
+func NewRefreshingTokenSource(ctx context.Context, conf *oauth2.Config, tok *oauth2.Token) *RefreshingTokenSource {
+	return &RefreshingTokenSource{
+		conf: conf,
+		ctx:  ctx,
+		now:  time.Now,
+	}
+}
+
+// NeedsRefresh reports whether tok has a refresh token and is within
+// refreshWindow of expiring.
+func (r *RefreshingTokenSource) NeedsRefresh(tok *oauth2.Token, refreshWindow time.Duration) bool {
+	return tok != nil && tok.RefreshToken != "" && !tok.Expiry.IsZero() && tok.Expiry.Sub(r.now()) <= refreshWindow
+}
+
+// Refresh unconditionally fetches a new token from the upstream provider
+// using tok's refresh token. Callers should check NeedsRefresh first and,
+// since concurrent requests may race to refresh the same session, share
+// the call through a refreshGroup so a provider that rotates refresh
+// tokens on use doesn't see a second request reuse one already consumed.
+func (r *RefreshingTokenSource) Refresh(tok *oauth2.Token) (*oauth2.Token, error) {
+	// conf.TokenSource(ctx, tok) would only perform a live refresh once tok
+	// is within the stdlib's own unexported expiryDelta (~10s) of expiry,
+	// which defeats a configurable refreshWindow. A token with no
+	// AccessToken is never Valid(), so building the source around a
+	// RefreshToken-only token forces a live refresh here instead.
+	source := r.conf.TokenSource(r.ctx, &oauth2.Token{RefreshToken: tok.RefreshToken})
+	return source.Token()
+}
+
+// refreshGroup de-duplicates concurrent refreshes of the same upstream
+// refresh token, so that two requests landing inside the refresh window at
+// once share a single live call to the provider instead of racing it.
+type refreshGroup struct {
+	mu    sync.Mutex
+	calls map[string]*refreshCall
+}
+
+type refreshCall struct {
+	wg    sync.WaitGroup
+	token *oauth2.Token
+	err   error
+}
+
+// Do calls fn and returns its result, unless another call for the same key
+// is already in flight, in which case it waits for and shares that result.
+func (g *refreshGroup) Do(key string, fn func() (*oauth2.Token, error)) (*oauth2.Token, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*refreshCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.token, c.err
+	}
+	c := &refreshCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.token, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return c.token, c.err
+}
+
+// RefreshToken returns middleware that refreshes the upstream OAuth token
+// stored in o.Store's session, if it is within DefaultRefreshWindow of
+// expiring, and re-issues the session cookie before calling next. If the
+// upstream provider rejects the refresh with invalid_grant, the session is
+// cleared and the request is redirected to o.FailureURL instead of being
+// passed downstream. Requests with no session, a session authenticated by
+// a different provider than o.Provider, or a session with no upstream
+// token to refresh, are passed through unchanged; the last case lets
+// several providers' RefreshToken middlewares, each keyed to a different
+// Provider, be chained over the same protected routes so that whichever
+// one actually authenticated the session is the one that refreshes it.
+func (o *OAuth2) RefreshToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.Store == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, provider, tok, err := o.Store.Load(r, o.Cookie)
+		if err != nil || tok == nil || provider != o.Provider.Name() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rts := NewRefreshingTokenSource(r.Context(), o.Provider.Config(), tok)
+		if !rts.NeedsRefresh(tok, DefaultRefreshWindow) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		refreshed, err := o.refreshes.Do(tok.RefreshToken, func() (*oauth2.Token, error) {
+			return rts.Refresh(tok)
+		})
+		if err != nil {
+			if isInvalidGrant(err) {
+				o.log(r).Error("Upstream token refresh rejected, ending session: ", err.Error())
+				o.Store.Clear(w, o.Cookie)
+				http.Redirect(w, r, o.FailureURL, http.StatusTemporaryRedirect)
+				return
+			}
+			o.log(r).Error("Unable to refresh upstream token ", err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := o.Store.Save(w, o.Cookie, principal, provider, refreshed); err != nil {
+			o.log(r).Error("Unable to save refreshed session ", err.Error())
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isInvalidGrant reports whether err is the upstream provider rejecting a
+// refresh token, typically because it was revoked or has expired.
+func isInvalidGrant(err error) bool {
+	return strings.Contains(err.Error(), "invalid_grant")
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRefreshingTokenSourceNeedsRefresh(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	rts := &RefreshingTokenSource{now: func() time.Time { return now }}
+
+	tests := []struct {
+		name string
+		tok  *oauth2.Token
+		want bool
+	}{
+		{
+			name: "nil token",
+			tok:  nil,
+			want: false,
+		},
+		{
+			name: "no refresh token",
+			tok:  &oauth2.Token{Expiry: now.Add(10 * time.Second)},
+			want: false,
+		},
+		{
+			name: "zero expiry",
+			tok:  &oauth2.Token{RefreshToken: "r"},
+			want: false,
+		},
+		{
+			name: "well outside the refresh window",
+			tok:  &oauth2.Token{RefreshToken: "r", Expiry: now.Add(DefaultRefreshWindow + time.Second)},
+			want: false,
+		},
+		{
+			name: "exactly at the refresh window boundary",
+			tok:  &oauth2.Token{RefreshToken: "r", Expiry: now.Add(DefaultRefreshWindow)},
+			want: true,
+		},
+		{
+			name: "inside the refresh window",
+			tok:  &oauth2.Token{RefreshToken: "r", Expiry: now.Add(DefaultRefreshWindow / 2)},
+			want: true,
+		},
+		{
+			name: "already expired",
+			tok:  &oauth2.Token{RefreshToken: "r", Expiry: now.Add(-time.Second)},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rts.NeedsRefresh(tt.tok, DefaultRefreshWindow); got != tt.want {
+				t.Errorf("NeedsRefresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshGroupDeduplicatesConcurrentCallers(t *testing.T) {
+	var g refreshGroup
+	var calls int32
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make([]*oauth2.Token, callers)
+	errs := make([]error, callers)
+
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = g.Do("same-refresh-token", func() (*oauth2.Token, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(10 * time.Millisecond)
+				return &oauth2.Token{AccessToken: "refreshed"}, nil
+			})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("fn was called %d times, want exactly 1", got)
+	}
+	for i, tok := range results {
+		if errs[i] != nil {
+			t.Errorf("caller %d got error %v", i, errs[i])
+		}
+		if tok == nil || tok.AccessToken != "refreshed" {
+			t.Errorf("caller %d got token %+v, want the shared refreshed token", i, tok)
+		}
+	}
+}
+
+func TestRefreshGroupDoesNotDeduplicateDifferentKeys(t *testing.T) {
+	var g refreshGroup
+	var calls int32
+
+	fn := func() (*oauth2.Token, error) {
+		atomic.AddInt32(&calls, 1)
+		return &oauth2.Token{}, nil
+	}
+
+	if _, err := g.Do("key-a", fn); err != nil {
+		t.Fatalf("Do(key-a): %v", err)
+	}
+	if _, err := g.Do("key-b", fn); err != nil {
+		t.Fatalf("Do(key-b): %v", err)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("fn was called %d times for distinct keys, want 2", got)
+	}
+}
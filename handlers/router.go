@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/influxdata/chronograf"
+	"github.com/influxdata/chronograf/handlers/providers"
+)
+
+// RouterConfig are the shared inputs needed to mount every OAuth2 provider
+// enabled via its environment variables onto a mux.
+type RouterConfig struct {
+	Mux           *http.ServeMux
+	Authenticator chronograf.Authenticator
+	Store         *SessionStore // Store, if set, encrypts sessions instead of relying on Authenticator's bare JWT cookie
+	SuccessURL    string        // SuccessURL is redirect location after successful authorization
+	FailureURL    string        // FailureURL is redirect location after authorization failure
+	Logger        chronograf.Logger
+}
+
+// NewRouter mounts /oauth/{provider}/login, /oauth/{provider}/callback, and
+// /oauth/{provider}/logout for every provider enabled via its environment
+// variables, and returns the names of the providers it registered along
+// with a Protect middleware. Github is registered under its own name
+// ("github"), which keeps it reachable at /oauth/github/* exactly as it
+// was before the other providers existed.
+//
+//	GH_CLIENT_ID, GH_CLIENT_SECRET, GH_ORGS, GH_TEAMS,
+//	GH_ALLOWED_DOMAINS, GH_ALLOWED_EMAILS
+//	GOOGLE_CLIENT_ID, GOOGLE_CLIENT_SECRET, GOOGLE_DOMAIN,
+//	GOOGLE_ALLOWED_DOMAINS, GOOGLE_ALLOWED_EMAILS
+//	GENERIC_OIDC_ISSUER, GENERIC_CLIENT_ID, GENERIC_CLIENT_SECRET,
+//	GENERIC_ALLOWED_DOMAINS, GENERIC_ALLOWED_EMAILS
+//
+// A provider is enabled by setting its client ID (or, for the generic OIDC
+// provider, its issuer); every other variable for that provider is
+// optional. Mount Protect around any route that requires an authenticated
+// session, e.g.:
+//
+//	registered, protect := NewRouter(cfg)
+//	mux.Handle("/chronograf/v1/", protect(apiHandler))
+//
+// so that the upstream OAuth token backing the session is refreshed, via
+// whichever registered provider actually issued it, before apiHandler runs.
+func NewRouter(cfg RouterConfig) (registered []string, protect func(http.Handler) http.Handler) {
+	var refreshers []func(http.Handler) http.Handler
+
+	mount := func(name string, provider Provider) {
+		o := NewOAuth2(provider, cfg.SuccessURL, cfg.FailureURL, cfg.Authenticator, cfg.Logger)
+		o.Store = cfg.Store
+
+		prefix := "/oauth/" + provider.Name()
+		cfg.Mux.Handle(prefix+"/login", o.Login())
+		cfg.Mux.Handle(prefix+"/callback", o.Callback())
+		cfg.Mux.Handle(prefix+"/logout", o.Logout())
+
+		refreshers = append(refreshers, o.RefreshToken)
+		registered = append(registered, name)
+	}
+
+	if clientID := os.Getenv("GH_CLIENT_ID"); clientID != "" {
+		mount("github", &providers.Github{
+			ClientID:       clientID,
+			ClientSecret:   os.Getenv("GH_CLIENT_SECRET"),
+			Organizations:  splitEnvList("GH_ORGS"),
+			Teams:          splitEnvList("GH_TEAMS"),
+			AllowedDomains: splitEnvList("GH_ALLOWED_DOMAINS"),
+			AllowedEmails:  splitEnvList("GH_ALLOWED_EMAILS"),
+		})
+	}
+
+	if clientID := os.Getenv("GOOGLE_CLIENT_ID"); clientID != "" {
+		mount("google", &providers.Google{
+			ClientID:       clientID,
+			ClientSecret:   os.Getenv("GOOGLE_CLIENT_SECRET"),
+			Domain:         os.Getenv("GOOGLE_DOMAIN"),
+			AllowedDomains: splitEnvList("GOOGLE_ALLOWED_DOMAINS"),
+			AllowedEmails:  splitEnvList("GOOGLE_ALLOWED_EMAILS"),
+		})
+	}
+
+	if issuer := os.Getenv("GENERIC_OIDC_ISSUER"); issuer != "" {
+		generic, err := providers.NewGeneric(issuer, os.Getenv("GENERIC_CLIENT_ID"), os.Getenv("GENERIC_CLIENT_SECRET"))
+		if err != nil {
+			cfg.Logger.Error("Unable to discover generic OIDC issuer ", issuer, ": ", err.Error())
+		} else {
+			generic.AllowedDomains = splitEnvList("GENERIC_ALLOWED_DOMAINS")
+			generic.AllowedEmails = splitEnvList("GENERIC_ALLOWED_EMAILS")
+			mount("generic", generic)
+		}
+	}
+
+	return registered, chainRefreshers(refreshers)
+}
+
+// chainRefreshers composes refreshers, each keyed to a different Provider,
+// into a single middleware. Every refresher but the one matching the
+// session's provider is a no-op, so they're safe to chain over any number
+// of enabled providers.
+func chainRefreshers(refreshers []func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		h := next
+		for i := len(refreshers) - 1; i >= 0; i-- {
+			h = refreshers[i](h)
+		}
+		return h
+	}
+}
+
+// splitEnvList reads a comma-separated environment variable into a slice,
+// trimming whitespace around each entry and dropping empty ones. It
+// returns nil, meaning "no restriction", if the variable is unset or
+// empty.
+func splitEnvList(name string) []string {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
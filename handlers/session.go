@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/influxdata/chronograf"
+	"golang.org/x/oauth2"
+)
+
+// maxCookieValueSize is conservatively below the ~4096 byte per-cookie limit
+// enforced by browsers, leaving headroom for the cookie's name, attributes,
+// and the rest of the Set-Cookie/Cookie header budget.
+const maxCookieValueSize = 3800
+
+// sessionData is the plaintext encrypted into a session's cookies.
+type sessionData struct {
+	Principal string        `json:"principal"`
+	Provider  string        `json:"provider,omitempty"`
+	Token     *oauth2.Token `json:"token,omitempty"`
+}
+
+// SessionStore encrypts a principal (and, optionally, the upstream OAuth
+// token used to obtain it) with AES-GCM and chunks the resulting ciphertext
+// across as many numbered cookies as needed to stay under the browser's
+// per-cookie size limit. This replaces carrying a bare JWT cookie, which
+// cannot hold an upstream access/refresh token without risking truncation.
+type SessionStore struct {
+	// Keys are the accepted AES-256 (32 byte) encryption keys, most recent
+	// first. Keys[0] encrypts new sessions; the remainder are accepted on
+	// read so that a key can be rotated without invalidating sessions
+	// already issued under the previous key.
+	Keys [][]byte
+}
+
+// NewSessionStore constructs a SessionStore that encrypts with key and
+// additionally accepts oldKeys when decrypting, to support key rotation.
+// Each key must be 32 bytes (AES-256).
+func NewSessionStore(key []byte, oldKeys ...[][]byte) (SessionStore, error) {
+	keys := [][]byte{key}
+	for _, ok := range oldKeys {
+		keys = append(keys, ok...)
+	}
+	for _, k := range keys {
+		if len(k) != 32 {
+			return SessionStore{}, errors.New("session store keys must be 32 bytes")
+		}
+	}
+	return SessionStore{Keys: keys}, nil
+}
+
+// Save encrypts principal, the name of the provider that authenticated it,
+// and the optional upstreamToken, and writes them to w as one or more
+// cookies sharing cookie.Name as a prefix. provider is later returned by
+// Load so that RefreshToken can refresh upstreamToken against the same
+// provider that issued it.
+func (s *SessionStore) Save(w http.ResponseWriter, cookie Cookie, principal chronograf.Principal, provider string, upstreamToken *oauth2.Token) error {
+	plaintext, err := json.Marshal(sessionData{
+		Principal: string(principal),
+		Provider:  provider,
+		Token:     upstreamToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := encrypt(s.Keys[0], plaintext)
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(ciphertext)
+	expires := time.Now().Add(cookie.Duration)
+
+	written := 0
+	for i := 0; ; i++ {
+		start := i * maxCookieValueSize
+		if start >= len(encoded) {
+			break
+		}
+		end := start + maxCookieValueSize
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     chunkName(cookie.Name, i),
+			Value:    encoded[start:end],
+			Expires:  expires,
+			HttpOnly: true,
+			Path:     "/",
+		})
+		written++
+	}
+
+	// A previous session saved to this same cookie name may have needed
+	// more chunks than this one does. Expire anything beyond what was just
+	// written so Load doesn't concatenate a fresh chunk 0 with a stale,
+	// unrelated chunk from an earlier, longer session.
+	clearChunks(w, cookie, written)
+
+	return nil
+}
+
+// Clear expires every chunk cookie belonging to cookie.Name. Since the
+// number of chunks used at Save time isn't known to the caller, it clears
+// up to maxSessionChunks, which comfortably covers any session this store
+// would ever write.
+func (s *SessionStore) Clear(w http.ResponseWriter, cookie Cookie) {
+	clearChunks(w, cookie, 0)
+}
+
+// clearChunks expires every chunk cookie belonging to cookie.Name from
+// index from onward, up to maxSessionChunks.
+func clearChunks(w http.ResponseWriter, cookie Cookie, from int) {
+	for i := from; i < maxSessionChunks; i++ {
+		http.SetCookie(w, &http.Cookie{
+			Name:     chunkName(cookie.Name, i),
+			Value:    "none",
+			Expires:  time.Now().Add(-1 * time.Hour),
+			HttpOnly: true,
+			Path:     "/",
+		})
+	}
+}
+
+// maxSessionChunks bounds how many numbered cookies Load will look for and
+// Clear will expire. At maxCookieValueSize bytes per chunk this comfortably
+// covers an encrypted principal plus a full OAuth2 token with refresh
+// token, id_token and expiry.
+const maxSessionChunks = 8
+
+// Load reassembles and decrypts the session cookies on r, trying each of
+// s.Keys in turn so that sessions issued under a since-rotated key remain
+// valid. It returns the principal, the name of the provider that
+// authenticated it, and, if one was saved, the upstream OAuth token.
+func (s *SessionStore) Load(r *http.Request, cookie Cookie) (chronograf.Principal, string, *oauth2.Token, error) {
+	var encoded string
+	for i := 0; i < maxSessionChunks; i++ {
+		c, err := r.Cookie(chunkName(cookie.Name, i))
+		if err != nil {
+			break
+		}
+		encoded += c.Value
+	}
+	if encoded == "" {
+		return "", "", nil, errors.New("no session cookie present")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("malformed session cookie: %v", err)
+	}
+
+	var lastErr error
+	for _, key := range s.Keys {
+		plaintext, err := decrypt(key, ciphertext)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var data sessionData
+		if err := json.Unmarshal(plaintext, &data); err != nil {
+			return "", "", nil, err
+		}
+		return chronograf.Principal(data.Principal), data.Provider, data.Token, nil
+	}
+
+	return "", "", nil, fmt.Errorf("unable to decrypt session: %v", lastErr)
+}
+
+func chunkName(name string, i int) string {
+	return fmt.Sprintf("%s_%d", name, i)
+}
+
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decrypt(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
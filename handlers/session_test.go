@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/chronograf"
+	"golang.org/x/oauth2"
+)
+
+func testKey(b byte) []byte {
+	k := make([]byte, 32)
+	for i := range k {
+		k[i] = b
+	}
+	return k
+}
+
+// saveAndLoad runs Save against a ResponseRecorder, copies the resulting
+// Set-Cookie headers onto a fresh request, and returns what Load sees.
+// Chunks that Save expired (Value "none") are left off the request, as a
+// browser would drop them.
+func saveAndLoad(t *testing.T, store SessionStore, cookie Cookie, principal chronograf.Principal, provider string, tok *oauth2.Token) (*http.Request, chronograf.Principal, string, *oauth2.Token, error) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	if err := store.Save(rec, cookie, principal, provider, tok); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		if c.Value == "none" {
+			continue
+		}
+		req.AddCookie(c)
+	}
+
+	p, gotProvider, loaded, err := store.Load(req, cookie)
+	return req, p, gotProvider, loaded, err
+}
+
+func TestSessionStoreSplitAndReassemble(t *testing.T) {
+	store, err := NewSessionStore(testKey(1))
+	if err != nil {
+		t.Fatalf("NewSessionStore: %v", err)
+	}
+	cookie := NewCookie()
+
+	// A refresh token long enough to force several chunks.
+	tok := &oauth2.Token{
+		AccessToken:  strings.Repeat("a", 9000),
+		RefreshToken: "refresh-token",
+	}
+
+	req, principal, provider, loaded, err := saveAndLoad(t, store, cookie, "user@example.com", "github", tok)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if principal != "user@example.com" {
+		t.Errorf("got principal %q, want %q", principal, "user@example.com")
+	}
+	if provider != "github" {
+		t.Errorf("got provider %q, want %q", provider, "github")
+	}
+	if loaded == nil || loaded.AccessToken != tok.AccessToken {
+		t.Errorf("token did not round-trip")
+	}
+
+	chunks := 0
+	for _, c := range req.Cookies() {
+		if strings.HasPrefix(c.Name, cookie.Name+"_") {
+			chunks++
+		}
+	}
+	if chunks < 2 {
+		t.Fatalf("expected the long token to be split across multiple chunks, got %d", chunks)
+	}
+}
+
+func TestSessionStoreSaveClearsStaleTrailingChunks(t *testing.T) {
+	store, err := NewSessionStore(testKey(1))
+	if err != nil {
+		t.Fatalf("NewSessionStore: %v", err)
+	}
+	cookie := NewCookie()
+
+	// First save a long-lived session requiring several chunks.
+	longTok := &oauth2.Token{AccessToken: strings.Repeat("a", 9000), RefreshToken: "r1"}
+	rec := httptest.NewRecorder()
+	if err := store.Save(rec, cookie, "user@example.com", "github", longTok); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	if got := len(req.Cookies()); got < 3 {
+		t.Fatalf("expected the first save to produce at least 3 chunk cookies, got %d", got)
+	}
+
+	// Now re-save a much shorter session on top, as RefreshToken middleware
+	// does after a token refresh, and simulate the browser applying the new
+	// Set-Cookie headers to the same cookie jar.
+	rec2 := httptest.NewRecorder()
+	if err := store.Save(rec2, cookie, "user@example.com", "github", &oauth2.Token{RefreshToken: "r2"}); err != nil {
+		t.Fatalf("second Save: %v", err)
+	}
+
+	// Merge the two Set-Cookie responses into one jar, exactly as a browser
+	// would: the second save's cookies win by name, and any chunk the
+	// second save expired (Value "none") is dropped rather than kept
+	// around stale.
+	jar := map[string]*http.Cookie{}
+	for _, c := range req.Cookies() {
+		jar[c.Name] = c
+	}
+	for _, c := range rec2.Result().Cookies() {
+		if c.Value == "none" {
+			delete(jar, c.Name)
+			continue
+		}
+		jar[c.Name] = c
+	}
+
+	req2 := httptest.NewRequest("GET", "/", nil)
+	for _, c := range jar {
+		req2.AddCookie(c)
+	}
+
+	principal, _, loaded, err := store.Load(req2, cookie)
+	if err != nil {
+		t.Fatalf("Load after shrinking Save returned error: %v", err)
+	}
+	if principal != "user@example.com" {
+		t.Errorf("got principal %q, want %q", principal, "user@example.com")
+	}
+	if loaded == nil || loaded.RefreshToken != "r2" {
+		t.Errorf("expected the freshly saved token to win, got %+v", loaded)
+	}
+}
+
+func TestSessionStoreTamperDetection(t *testing.T) {
+	store, err := NewSessionStore(testKey(1))
+	if err != nil {
+		t.Fatalf("NewSessionStore: %v", err)
+	}
+	cookie := NewCookie()
+
+	rec := httptest.NewRecorder()
+	if err := store.Save(rec, cookie, "user@example.com", "github", nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		if c.Value == "none" {
+			continue
+		}
+		if c.Name == cookie.Name+"_0" {
+			c.Value = c.Value[:len(c.Value)-4] + "abcd"
+		}
+		req.AddCookie(c)
+	}
+
+	if _, _, _, err := store.Load(req, cookie); err == nil {
+		t.Fatal("expected Load to reject a tampered session, got nil error")
+	}
+}
+
+func TestSessionStoreKeyRotation(t *testing.T) {
+	oldKey := testKey(1)
+	newKey := testKey(2)
+
+	oldStore, err := NewSessionStore(oldKey)
+	if err != nil {
+		t.Fatalf("NewSessionStore(old): %v", err)
+	}
+	cookie := NewCookie()
+
+	rec := httptest.NewRecorder()
+	if err := oldStore.Save(rec, cookie, "user@example.com", "github", nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		if c.Value == "none" {
+			continue
+		}
+		req.AddCookie(c)
+	}
+
+	// A store rotated to a new primary key must still accept a session
+	// encrypted under the old one, as long as it's listed among the
+	// accepted keys.
+	rotatedStore, err := NewSessionStore(newKey, [][]byte{oldKey})
+	if err != nil {
+		t.Fatalf("NewSessionStore(rotated): %v", err)
+	}
+
+	principal, _, _, err := rotatedStore.Load(req, cookie)
+	if err != nil {
+		t.Fatalf("Load with rotated keys returned error: %v", err)
+	}
+	if principal != "user@example.com" {
+		t.Errorf("got principal %q, want %q", principal, "user@example.com")
+	}
+
+	// A store that doesn't know the old key at all must reject it.
+	strictStore, err := NewSessionStore(newKey)
+	if err != nil {
+		t.Fatalf("NewSessionStore(strict): %v", err)
+	}
+	if _, _, _, err := strictStore.Load(req, cookie); err == nil {
+		t.Fatal("expected Load to reject a session encrypted under an unknown key")
+	}
+}